@@ -1,36 +1,75 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"container/list"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
 type CodeExecutionRequest struct {
-	Code      string `json:"code"`
-	InputData string `json:"inputData,omitempty"`
-	Timeout   int    `json:"timeout,omitempty"`
+	Code      string            `json:"code"`
+	InputData string            `json:"inputData,omitempty"`
+	Timeout   int               `json:"timeout,omitempty"`
+	WithVet   bool              `json:"withVet,omitempty"`
+	Files     map[string]string `json:"files,omitempty"`
 }
 
+// CodeExecutionResponse.Status is one of "success", "compile_error",
+// "vet_error", "runtime_error", "timeout", or "error" (rejected before a
+// compile/run phase was ever attempted, e.g. a disallowed import).
 type CodeExecutionResponse struct {
 	Output        string  `json:"output"`
 	Error         string  `json:"error"`
 	ExecutionTime float64 `json:"executionTime"`
 	Status        string  `json:"status"`
+	ExitCode      int     `json:"exitCode"`
+	VetOutput     string  `json:"vetOutput,omitempty"`
+
+	// IsTest and the fields below are only populated when the submission was
+	// detected as a go test file (see isTestModule): it's run with
+	// `go test -json` instead of being compiled and executed as a program.
+	IsTest      bool         `json:"isTest,omitempty"`
+	TestsPassed int          `json:"testsPassed,omitempty"`
+	TestsFailed int          `json:"testsFailed,omitempty"`
+	TestResults []TestResult `json:"testResults,omitempty"`
+}
+
+// TestResult is one Test/Benchmark/Example function's outcome, parsed from a
+// `go test -json` event stream.
+type TestResult struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"` // "pass", "fail", or "skip"
+	Output  string  `json:"output"`
+	Elapsed float64 `json:"elapsed"`
 }
 
 type CodeValidationRequest struct {
-	Code string `json:"code"`
+	Code  string            `json:"code"`
+	Files map[string]string `json:"files,omitempty"`
 }
 
 type CodeValidationResponse struct {
@@ -39,24 +78,99 @@ type CodeValidationResponse struct {
 	Warnings []string `json:"warnings"`
 }
 
+// availableLibraries is the import allowlist advertised by infoHandler and
+// enforced by ValidateSyntax/ExecuteCode. Entries ending in "/*" allow any
+// subpackage of that prefix (e.g. "crypto/*" allows "crypto/sha256").
+var availableLibraries = []string{
+	"fmt", "os", "strings", "strconv", "math", "sort",
+	"time", "bufio", "bytes", "io", "regexp", "unicode",
+	"crypto/*", "encoding/*", "path/filepath", "runtime",
+	"testing", "testing/quick", "reflect",
+}
+
+// disallowedImports are never permitted regardless of availableLibraries,
+// since they let user code escape the process sandboxing entirely.
+var disallowedImports = map[string]string{
+	"unsafe":  "package \"unsafe\" is not allowed",
+	"syscall": "package \"syscall\" is not allowed",
+	"os/exec": "package \"os/exec\" is not allowed",
+	"net":     "package \"net\" is not allowed",
+	"plugin":  "package \"plugin\" is not allowed",
+}
+
+// disallowedDirectivePrefixes flags compiler directive comments that can be
+// used to reach into unexported runtime internals or link against C code.
+var disallowedDirectivePrefixes = []string{
+	"//go:linkname",
+	"//go:cgo_",
+}
+
+func importAllowed(path string) bool {
+	for _, lib := range availableLibraries {
+		if strings.HasSuffix(lib, "/*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(lib, "*")) {
+				return true
+			}
+			continue
+		}
+		if path == lib {
+			return true
+		}
+	}
+	return false
+}
+
 type GoExecutor struct {
 	MaxExecutionTime int
+	MaxCompileTime   int
 	MaxMemoryMB      int
 	MaxCodeSizeKB    int
+	Sandbox          Sandbox
+	GoCacheDir       string
+}
+
+// defaultMaxCompileTime leaves room for a cold GOCACHE (see goCacheDir): a cold
+// build of even a trivial program routinely takes 10+ seconds, so a much
+// shorter ceiling rejects valid programs on every cache miss. Configurable
+// via MAX_COMPILE_TIME_SECONDS for deployments with slower toolchains/disks.
+const defaultMaxCompileTime = 30
+
+func maxCompileTimeFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("MAX_COMPILE_TIME_SECONDS")); err == nil && v > 0 {
+		return v
+	}
+	return defaultMaxCompileTime
+}
+
+// goCacheDir resolves the GOCACHE directory shared across requests
+// (configurable via GOCACHE_DIR, default a fixed subdirectory of the OS temp
+// dir). It must persist across requests rather than living under each
+// request's tempDir: a per-request cache starts every compile cold, which is
+// what made MaxCompileTime unworkable in the first place.
+func goCacheDir() string {
+	dir := os.Getenv("GOCACHE_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "go-executor-gocache")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("failed to create GOCACHE dir %s: %v", dir, err)
+	}
+	return dir
 }
 
 func NewGoExecutor() *GoExecutor {
 	return &GoExecutor{
 		MaxExecutionTime: 30,
+		MaxCompileTime:   maxCompileTimeFromEnv(),
 		MaxMemoryMB:      128,
 		MaxCodeSizeKB:    50,
+		Sandbox:          newSandbox(),
+		GoCacheDir:       goCacheDir(),
 	}
 }
 
-func (g *GoExecutor) ExecuteCode(code, inputData string, timeout int) CodeExecutionResponse {
-	if timeout > 0 && timeout <= 60 {
-		g.MaxExecutionTime = timeout
-	}
+func (g *GoExecutor) ExecuteCode(code, inputData string, timeout int, withVet bool, files map[string]string) CodeExecutionResponse {
+	execTimeout := g.effectiveTimeout(timeout)
 
 	// Validate code size
 	codeSizeKB := float64(utf8.RuneCountInString(code)) / 1024.0
@@ -71,48 +185,337 @@ func (g *GoExecutor) ExecuteCode(code, inputData string, timeout int) CodeExecut
 
 	start := time.Now()
 
-	// Create temporary directory
-	tempDir, err := os.MkdirTemp("", "go_exec_*")
+	// Resolve/validate/write files before ever invoking the toolchain, and
+	// reject disallowed imports/constructs before ever touching the filesystem.
+	tempDir, sourceFiles, err := g.prepareBuildDir(code, files)
 	if err != nil {
 		return CodeExecutionResponse{
 			Output:        "",
-			Error:         fmt.Sprintf("Failed to create temp directory: %v", err),
+			Error:         err.Error(),
 			ExecutionTime: time.Since(start).Seconds(),
 			Status:        "error",
 		}
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Create Go file
-	goFile := filepath.Join(tempDir, "main.go")
-	fullCode := g.createRestrictedCode(code)
+	var result CodeExecutionResponse
+	if isTestModule(sourceFiles) {
+		result = g.runGoTest(tempDir, isOnlyBenchmarkModule(sourceFiles), execTimeout)
+	} else {
+		result = g.runGoCode(tempDir, inputData, withVet, execTimeout)
+	}
+	result.ExecutionTime = time.Since(start).Seconds()
 
-	err = os.WriteFile(goFile, []byte(fullCode), 0644)
+	return result
+}
+
+// effectiveTimeout returns a caller-supplied per-request timeout when it is
+// between 1 and 60 seconds (it may exceed MaxExecutionTime), and
+// MaxExecutionTime otherwise (0 meaning "unset"). It never mutates MaxExecutionTime
+// itself: that field is shared across concurrent requests (and read by
+// infoHandler), so the effective timeout must be threaded through as a
+// plain value instead of written onto the shared *GoExecutor.
+func (g *GoExecutor) effectiveTimeout(timeout int) int {
+	if timeout > 0 && timeout <= 60 {
+		return timeout
+	}
+	return g.MaxExecutionTime
+}
+
+// prepareBuildDir resolves code/files into a source-file set, statically
+// validates it, and materializes it into a fresh temp directory (writing a
+// go.mod only if the submission didn't supply one). Caller owns the
+// returned directory and must os.RemoveAll it. The resolved source-file set
+// is also returned so callers can branch on isTestModule without having to
+// re-derive it (and re-run the txtar/Files resolution) themselves.
+func (g *GoExecutor) prepareBuildDir(code string, files map[string]string) (string, map[string]string, error) {
+	sourceFiles, err := g.resolveSourceFiles(code, files)
 	if err != nil {
-		return CodeExecutionResponse{
-			Output:        "",
-			Error:         fmt.Sprintf("Failed to write code file: %v", err),
-			ExecutionTime: time.Since(start).Seconds(),
-			Status:        "error",
+		return "", nil, err
+	}
+
+	validation := g.validateSourceFiles(sourceFiles)
+	if !validation.IsValid {
+		return "", nil, fmt.Errorf("%s", strings.Join(validation.Errors, "\n"))
+	}
+
+	tempDir, err := os.MkdirTemp("", "go_exec_*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	if err := writeSourceFiles(tempDir, sourceFiles); err != nil {
+		os.RemoveAll(tempDir)
+		return "", nil, fmt.Errorf("failed to write code file: %w", err)
+	}
+
+	if _, hasGoMod := sourceFiles["go.mod"]; !hasGoMod {
+		// The module path must not be "main": `go test` builds a test binary
+		// that imports the package under test by its import path, and Go
+		// refuses to import a package literally named "main" ("cannot import
+		// \"main\""). A non-"main" module path doesn't affect `go build .` /
+		// `go run .` of a package main, which resolve by directory regardless.
+		goModContent := "module sandbox\n\ngo 1.21\n"
+		if err := os.WriteFile(filepath.Join(tempDir, "go.mod"), []byte(goModContent), 0644); err != nil {
+			os.RemoveAll(tempDir)
+			return "", nil, fmt.Errorf("failed to create go.mod: %w", err)
 		}
 	}
 
-	// Execute Go code
-	result := g.runGoCode(tempDir, inputData)
-	result.ExecutionTime = time.Since(start).Seconds()
+	return tempDir, sourceFiles, nil
+}
 
-	return result
+// safeRelPath rejects absolute paths and ".." components so a submitted
+// Files map (or txtar payload) can't escape tempDir.
+func safeRelPath(p string) error {
+	if p == "" {
+		return fmt.Errorf("file path must not be empty")
+	}
+	if filepath.IsAbs(p) {
+		return fmt.Errorf("file path %q must be relative", p)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(p), "/") {
+		if part == ".." {
+			return fmt.Errorf("file path %q must not contain \"..\"", p)
+		}
+	}
+	return nil
+}
+
+// parseTxtar splits a txtar-style payload ("-- path --\ncontent\n-- other --\n...")
+// into named files, the same minimal multi-file format the Playground
+// sandbox accepts. Returns ok=false if code contains no file markers, in
+// which case it should be treated as a plain single-snippet submission.
+func parseTxtar(code string) (map[string]string, bool) {
+	lines := strings.Split(code, "\n")
+	type section struct {
+		name string
+		body []string
+	}
+	var sections []section
+
+	for _, line := range lines {
+		if name, ok := txtarMarker(line); ok {
+			sections = append(sections, section{name: name})
+			continue
+		}
+		if len(sections) == 0 {
+			continue
+		}
+		last := &sections[len(sections)-1]
+		last.body = append(last.body, line)
+	}
+
+	if len(sections) == 0 {
+		return nil, false
+	}
+
+	files := make(map[string]string, len(sections))
+	for _, s := range sections {
+		files[s.name] = strings.Join(s.body, "\n")
+	}
+	return files, true
+}
+
+func txtarMarker(line string) (string, bool) {
+	if !strings.HasPrefix(line, "-- ") || !strings.HasSuffix(line, " --") {
+		return "", false
+	}
+	name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "-- "), " --"))
+	if name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// resolveSourceFiles determines the set of files to place in the build
+// directory: an explicit Files map takes precedence, then a txtar-formatted
+// Code payload, then a single main_test.go (not wrapped) when Code is
+// detected as a test snippet, else a single wrapped main.go built from Code.
+func (g *GoExecutor) resolveSourceFiles(code string, files map[string]string) (map[string]string, error) {
+	if len(files) > 0 {
+		out := make(map[string]string, len(files))
+		for name, content := range files {
+			if err := safeRelPath(name); err != nil {
+				return nil, err
+			}
+			out[name] = content
+		}
+		return out, nil
+	}
+
+	if parsed, ok := parseTxtar(code); ok {
+		out := make(map[string]string, len(parsed))
+		for name, content := range parsed {
+			if err := safeRelPath(name); err != nil {
+				return nil, err
+			}
+			out[name] = content
+		}
+		return out, nil
+	}
+
+	if isTest, _ := detectTestFuncs(code); isTest {
+		return map[string]string{"main_test.go": g.createTestCode(code)}, nil
+	}
+
+	return map[string]string{"main.go": g.createRestrictedCode(code)}, nil
+}
+
+// writeSourceFiles materializes a resolved file set under tempDir, creating
+// any intermediate directories a multi-package submission needs.
+func writeSourceFiles(tempDir string, files map[string]string) error {
+	for name, content := range files {
+		full := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasMainFunc reports whether file declares a top-level func main() with no receiver.
+func hasMainFunc(file *ast.File) bool {
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyUserCode parses userCode with go/parser instead of relying on
+// substring matching (which is fooled by strings/comments containing
+// "package main" or "func main()"). It returns "complete" when the code is
+// already a self-contained package main file, "main_func_only" when it
+// declares func main() but is missing the package clause, and "snippet"
+// when it's a bare sequence of statements to be wrapped.
+func classifyUserCode(userCode string) string {
+	fset := token.NewFileSet()
+	if file, err := parser.ParseFile(fset, "", userCode, 0); err == nil {
+		if file.Name != nil && file.Name.Name == "main" {
+			return "complete"
+		}
+	}
+
+	fset = token.NewFileSet()
+	if file, err := parser.ParseFile(fset, "", "package main\n"+userCode, 0); err == nil {
+		if hasMainFunc(file) {
+			return "main_func_only"
+		}
+	}
+
+	return "snippet"
+}
+
+// detectTestFuncs parses userCode (trying it first as a complete file, then
+// wrapped in "package main" the same way classifyUserCode does) and reports
+// whether it declares any top-level Test*/Benchmark*/Example*/Fuzz* function
+// recognized by the `go test` tool. onlyBenchmark is true when Benchmark
+// functions are present but no Test/Example/Fuzz function is, so the caller
+// can pick `-bench` over the default `-run .`.
+func detectTestFuncs(userCode string) (isTest, onlyBenchmark bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", userCode, 0)
+	if err != nil {
+		file, err = parser.ParseFile(fset, "", "package main\n"+userCode, 0)
+		if err != nil {
+			return false, false
+		}
+	}
+
+	hasTest, hasBenchmark := false, false
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+		switch {
+		case isTestFuncName(fn.Name.Name, "Test"),
+			isTestFuncName(fn.Name.Name, "Example"),
+			isTestFuncName(fn.Name.Name, "Fuzz"):
+			hasTest = true
+		case isTestFuncName(fn.Name.Name, "Benchmark"):
+			hasBenchmark = true
+		}
+	}
+
+	return hasTest || hasBenchmark, hasBenchmark && !hasTest
+}
+
+// isTestFuncName reports whether name is prefix followed by an upper-case
+// letter or underscore, e.g. "TestFoo" or "Test_foo" but not "Testable";
+// the bare prefix itself (e.g. "Test") does not count.
+func isTestFuncName(name, prefix string) bool {
+	rest := strings.TrimPrefix(name, prefix)
+	if rest == name || rest == "" {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return r == '_' || unicode.IsUpper(r)
+}
+
+// isTestModule reports whether any .go file in a resolved source set looks
+// like a go test file, either by name or by declaring a recognized
+// Test/Benchmark/Example/Fuzz function.
+func isTestModule(files map[string]string) bool {
+	for name, content := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			return true
+		}
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if isTest, _ := detectTestFuncs(content); isTest {
+			return true
+		}
+	}
+	return false
+}
+
+// isOnlyBenchmarkModule reports whether a test module has Benchmark
+// functions but no Test/Example/Fuzz function, in which case runGoTest
+// should pass `-bench` instead of relying on the default `-run .`.
+func isOnlyBenchmarkModule(files map[string]string) bool {
+	hasTest, hasBenchmark := false, false
+	for name, content := range files {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		isTest, onlyBenchmark := detectTestFuncs(content)
+		if !isTest {
+			continue
+		}
+		if onlyBenchmark {
+			hasBenchmark = true
+		} else {
+			hasTest = true
+		}
+	}
+	return hasBenchmark && !hasTest
+}
+
+// createTestCode prepares a detected test snippet to be written as
+// main_test.go: unlike createRestrictedCode it never wraps the code in a
+// func main, since `go test` supplies its own entry point, and it only adds
+// the package clause when the snippet doesn't already declare one.
+func (g *GoExecutor) createTestCode(userCode string) string {
+	fset := token.NewFileSet()
+	if file, err := parser.ParseFile(fset, "", userCode, 0); err == nil && file.Name != nil {
+		return userCode
+	}
+	return "package main\n\n" + userCode
 }
 
 func (g *GoExecutor) createRestrictedCode(userCode string) string {
-	// Check if user code already has a complete Go program with package declaration
-	if strings.Contains(userCode, "package main") {
+	switch classifyUserCode(userCode) {
+	case "complete":
 		// User provided a complete Go program, use it as-is
 		return userCode
-	}
-	
-	// Check if user code has main function but no package declaration
-	if strings.Contains(userCode, "func main()") {
+	case "main_func_only":
 		// User provided their own main function, just add minimal imports and package
 		restrictedCode := `package main
 
@@ -123,9 +526,9 @@ import (
 	"runtime"
 )
 
-// Security restrictions
+// Throttle scheduler parallelism; real isolation is enforced by Sandbox
+// in runGoCode, not by this process itself.
 func init() {
-	// Limit goroutines
 	runtime.GOMAXPROCS(1)
 }
 
@@ -143,9 +546,9 @@ import (
 	"runtime"
 )
 
-// Security restrictions
+// Throttle scheduler parallelism; real isolation is enforced by Sandbox
+// in runGoCode, not by this process itself.
 func init() {
-	// Limit goroutines
 	runtime.GOMAXPROCS(1)
 }
 
@@ -178,32 +581,246 @@ func main() {
 	return restrictedCode
 }
 
-func (g *GoExecutor) runGoCode(tempDir, inputData string) CodeExecutionResponse {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(g.MaxExecutionTime)*time.Second)
-	defer cancel()
+// Sandbox builds the *exec.Cmd that runs an already-compiled binary under
+// some form of OS-level isolation. It replaces the cosmetic
+// runtime.GOMAXPROCS(1) call in createRestrictedCode, which only throttles
+// scheduler parallelism inside the same process and does nothing to stop
+// user code from opening files, dialing the network, or spawning
+// subprocesses. Compilation always happens on the host; only the resulting
+// binary is executed through the sandbox.
+type Sandbox interface {
+	// Name identifies the backend, surfaced on /info for operators.
+	Name() string
+	// Command builds the command that runs binPath with workDir as its
+	// working directory, applying a memory ceiling derived from maxMemoryMB.
+	Command(ctx context.Context, binPath, workDir string, maxMemoryMB int) (*exec.Cmd, error)
+}
 
-	// Create go.mod file
-	goModContent := "module main\n\ngo 1.21\n"
-	goModFile := filepath.Join(tempDir, "go.mod")
-	err := os.WriteFile(goModFile, []byte(goModContent), 0644)
-	if err != nil {
-		return CodeExecutionResponse{
-			Output: "",
-			Error:  fmt.Sprintf("Failed to create go.mod: %v", err),
-			Status: "error",
+// unsafeSandbox preserves the historical behavior and is the only backend
+// guaranteed to work everywhere: the binary runs directly as the service's
+// own user, with network access and no memory ceiling (maxMemoryMB is
+// ignored); its environment is whatever the caller sets. It exists so the
+// service keeps working on hosts without bwrap/nsjail/gVisor installed, but
+// provides no real isolation beyond the import allowlist already enforced
+// at validation time.
+type unsafeSandbox struct{}
+
+func (unsafeSandbox) Name() string { return "unsafe" }
+
+func (unsafeSandbox) Command(ctx context.Context, binPath, workDir string, maxMemoryMB int) (*exec.Cmd, error) {
+	log.Printf("WARNING: running user code with the \"unsafe\" sandbox backend (no OS-level isolation) " +
+		"- set SANDBOX_BACKEND=bwrap, nsjail, or docker for real sandboxing")
+	cmd := exec.CommandContext(ctx, binPath)
+	cmd.Dir = workDir
+	return cmd, nil
+}
+
+// bwrapHostBinDirs are bound read-only into the sandbox so the /bin/sh
+// wrapper Command execs has a shell (and the dynamic libs it needs) to run,
+// on the distros that split them across these paths. Missing ones are
+// skipped rather than erroring, since not every host has all of them.
+var bwrapHostBinDirs = []string{"/bin", "/sbin", "/usr", "/lib", "/lib64"}
+
+// bwrapSandbox runs the binary under bubblewrap: network, PID, UTS, and IPC
+// namespaces are unshared, all capabilities are dropped, GOROOT is bound
+// read-only (the binary still needs it for any embedded runtime lookups),
+// and an RLIMIT_AS-style memory ceiling is applied via a shell ulimit
+// wrapper since os/exec has no direct rlimit knob. The host's shell and
+// library directories are also bound read-only, since bwrap otherwise
+// starts from an empty mount namespace with no /bin/sh for that wrapper
+// to exec.
+type bwrapSandbox struct {
+	bwrapPath string
+}
+
+func (bwrapSandbox) Name() string { return "bwrap" }
+
+func (s bwrapSandbox) Command(ctx context.Context, binPath, workDir string, maxMemoryMB int) (*exec.Cmd, error) {
+	goroot := runtime.GOROOT()
+	args := []string{
+		"--unshare-net",
+		"--unshare-pid",
+		"--unshare-uts",
+		"--unshare-ipc",
+		"--die-with-parent",
+		"--cap-drop", "ALL",
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--tmpfs", "/tmp",
+		"--ro-bind", goroot, goroot,
+	}
+	for _, dir := range bwrapHostBinDirs {
+		if _, err := os.Stat(dir); err == nil {
+			args = append(args, "--ro-bind", dir, dir)
 		}
 	}
+	args = append(args,
+		"--bind", workDir, workDir,
+		"--chdir", workDir,
+		"--",
+		"/bin/sh", "-c", `ulimit -v "$1"; exec "$0"`, binPath, strconv.Itoa(maxMemoryMB*1024),
+	)
+	return exec.CommandContext(ctx, s.bwrapPath, args...), nil
+}
 
-	// Run go code
-	cmd := exec.CommandContext(ctx, "go", "run", "main.go")
-	cmd.Dir = tempDir
-	cmd.Env = []string{
+// nsjailSandbox runs the binary under nsjail, Google's namespace+seccomp
+// jail, applying the same memory ceiling via nsjail's native --rlimit_as.
+type nsjailSandbox struct {
+	nsjailPath string
+}
+
+func (nsjailSandbox) Name() string { return "nsjail" }
+
+func (s nsjailSandbox) Command(ctx context.Context, binPath, workDir string, maxMemoryMB int) (*exec.Cmd, error) {
+	goroot := runtime.GOROOT()
+	args := []string{
+		"--quiet",
+		"--mode", "o",
+		"--chroot", "/",
+		"--cwd", workDir,
+		"--rlimit_as", strconv.Itoa(maxMemoryMB),
+		"--disable_clone_newnet=false",
+		"--bindmount_ro", fmt.Sprintf("%s:%s", goroot, goroot),
+		"--bindmount", fmt.Sprintf("%s:%s", workDir, workDir),
+		"--",
+		binPath,
+	}
+	return exec.CommandContext(ctx, s.nsjailPath, args...), nil
+}
+
+// dockerSandbox runs the binary inside a container using the gVisor
+// (runsc) runtime unless SANDBOX_DOCKER_RUNTIME names another, giving the strongest isolation of the
+// three backends at the cost of per-request container start latency.
+type dockerSandbox struct {
+	dockerPath    string
+	dockerRuntime string
+	image         string
+}
+
+func (dockerSandbox) Name() string { return "docker" }
+
+func (s dockerSandbox) Command(ctx context.Context, binPath, workDir string, maxMemoryMB int) (*exec.Cmd, error) {
+	args := []string{
+		"run", "--rm", "-i",
+		"--runtime", s.dockerRuntime,
+		"--network", "none",
+		"--cap-drop", "ALL",
+		"--pids-limit", "64",
+		"--memory", fmt.Sprintf("%dm", maxMemoryMB),
+		"-v", fmt.Sprintf("%s:%s:ro", workDir, workDir),
+		"-w", workDir,
+		s.image,
+		filepath.Join(workDir, filepath.Base(binPath)),
+	}
+	return exec.CommandContext(ctx, s.dockerPath, args...), nil
+}
+
+// newSandbox selects a backend based on the SANDBOX_BACKEND env var
+// ("bwrap", "nsjail", "docker"/"gvisor"), falling back to unsafeSandbox
+// when unset, unrecognized, or the backing tool isn't on PATH.
+func newSandbox() Sandbox {
+	backend := os.Getenv("SANDBOX_BACKEND")
+	switch backend {
+	case "bwrap":
+		if path, err := exec.LookPath("bwrap"); err == nil {
+			return bwrapSandbox{bwrapPath: path}
+		}
+	case "nsjail":
+		if path, err := exec.LookPath("nsjail"); err == nil {
+			return nsjailSandbox{nsjailPath: path}
+		}
+	case "docker", "gvisor":
+		if path, err := exec.LookPath("docker"); err == nil {
+			dockerRuntime := os.Getenv("SANDBOX_DOCKER_RUNTIME")
+			if dockerRuntime == "" {
+				dockerRuntime = "runsc"
+			}
+			image := os.Getenv("SANDBOX_DOCKER_IMAGE")
+			if image == "" {
+				image = "scratch"
+			}
+			return dockerSandbox{dockerPath: path, dockerRuntime: dockerRuntime, image: image}
+		}
+	case "", "unsafe":
+		return unsafeSandbox{}
+	default:
+		log.Printf("unknown SANDBOX_BACKEND %q, falling back to the unsafe sandbox", backend)
+		return unsafeSandbox{}
+	}
+
+	log.Printf("SANDBOX_BACKEND=%s requested but its backing tool was not found on PATH, falling back to the unsafe sandbox", backend)
+	return unsafeSandbox{}
+}
+
+// goEnv builds the restricted environment shared by the compile, vet, and
+// run phases: no inherited GOPATH, and GOCACHE pointed at GoCacheDir (shared
+// and persistent across requests, not scoped to the per-request tempDir) so
+// compiles hit a warm build cache instead of starting cold every time.
+func (g *GoExecutor) goEnv(tempDir string) []string {
+	return []string{
 		"PATH=" + os.Getenv("PATH"),
-		"GOCACHE=" + filepath.Join(tempDir, ".cache"),
+		"GOCACHE=" + g.GoCacheDir,
 		"HOME=" + tempDir,
 		"GO111MODULE=on",
 		"GOPATH=",
 	}
+}
+
+// compileCode builds the program into binPath under MaxCompileTime, kept
+// separate from MaxExecutionTime so a slow compile can't eat the user's
+// runtime budget.
+func (g *GoExecutor) compileCode(tempDir, binPath string) (output string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(g.MaxCompileTime)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", binPath, ".")
+	cmd.Dir = tempDir
+	cmd.Env = g.goEnv(tempDir)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("Compilation timed out after %d seconds", g.MaxCompileTime), ctx.Err()
+	}
+	return strings.TrimSpace(stderr.String()), err
+}
+
+// runVet runs `go vet ./...` against the generated module, returning its
+// combined output. Run concurrently with compileCode so it doesn't add to
+// the user-visible compile latency.
+func (g *GoExecutor) runVet(tempDir string) (output string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(g.MaxCompileTime)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "go", "vet", "./...")
+	cmd.Dir = tempDir
+	cmd.Env = g.goEnv(tempDir)
+
+	var combined bytes.Buffer
+	cmd.Stdout = &combined
+	cmd.Stderr = &combined
+
+	err = cmd.Run()
+	return strings.TrimSpace(combined.String()), err
+}
+
+// execBinary runs the already-compiled binary under execTimeout, now that
+// compilation is no longer sharing the same budget.
+func (g *GoExecutor) execBinary(binPath, tempDir, inputData string, execTimeout int) CodeExecutionResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(execTimeout)*time.Second)
+	defer cancel()
+
+	cmd, err := g.Sandbox.Command(ctx, binPath, tempDir, g.MaxMemoryMB)
+	if err != nil {
+		return CodeExecutionResponse{
+			Output: "",
+			Error:  fmt.Sprintf("Failed to prepare sandbox: %v", err),
+			Status: "error",
+		}
+	}
+	cmd.Env = g.goEnv(tempDir)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -221,35 +838,33 @@ func (g *GoExecutor) runGoCode(tempDir, inputData string) CodeExecutionResponse
 	if ctx.Err() == context.DeadlineExceeded {
 		return CodeExecutionResponse{
 			Output: "",
-			Error:  fmt.Sprintf("Code execution timed out after %d seconds", g.MaxExecutionTime),
+			Error:  fmt.Sprintf("Code execution timed out after %d seconds", execTimeout),
 			Status: "timeout",
 		}
 	}
 
 	if err != nil {
-		// Check if it's a timeout exit
 		if exitError, ok := err.(*exec.ExitError); ok {
-			if status, ok := exitError.Sys().(syscall.WaitStatus); ok {
-				if status.ExitStatus() == 124 {
-					return CodeExecutionResponse{
-						Output: "",
-						Error:  fmt.Sprintf("Code execution timed out after %d seconds", g.MaxExecutionTime),
-						Status: "timeout",
-					}
+			if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.ExitStatus() == 124 {
+				return CodeExecutionResponse{
+					Output: "",
+					Error:  fmt.Sprintf("Code execution timed out after %d seconds", execTimeout),
+					Status: "timeout",
 				}
 			}
-		}
 
-		status := "error"
-		errorMsg := stderrStr
-		if errorMsg == "" {
-			errorMsg = err.Error()
+			return CodeExecutionResponse{
+				Output:   stdoutStr,
+				Error:    stderrStr,
+				Status:   "runtime_error",
+				ExitCode: exitError.ExitCode(),
+			}
 		}
 
 		return CodeExecutionResponse{
 			Output: stdoutStr,
-			Error:  errorMsg,
-			Status: status,
+			Error:  err.Error(),
+			Status: "runtime_error",
 		}
 	}
 
@@ -260,72 +875,677 @@ func (g *GoExecutor) runGoCode(tempDir, inputData string) CodeExecutionResponse
 	}
 }
 
-func (g *GoExecutor) ValidateSyntax(code string) CodeValidationResponse {
-	tempDir, err := os.MkdirTemp("", "go_validate_*")
-	if err != nil {
-		return CodeValidationResponse{
-			IsValid:  false,
-			Errors:   []string{fmt.Sprintf("Failed to create temp directory: %v", err)},
-			Warnings: []string{},
+// runGoCode compiles the program, optionally vets it in parallel, then
+// executes the resulting binary. Status reflects whichever phase failed:
+// "compile_error" takes priority, then "runtime_error", then "vet_error"
+// (vet issues never block a program that ran and exited cleanly).
+func (g *GoExecutor) runGoCode(tempDir, inputData string, withVet bool, execTimeout int) CodeExecutionResponse {
+	var vetOutput string
+	var vetErr error
+	var vetWG sync.WaitGroup
+	if withVet {
+		vetWG.Add(1)
+		go func() {
+			defer vetWG.Done()
+			vetOutput, vetErr = g.runVet(tempDir)
+		}()
+	}
+
+	binPath := filepath.Join(tempDir, "prog")
+	compileOutput, compileErr := g.compileCode(tempDir, binPath)
+	vetWG.Wait()
+
+	if compileErr != nil {
+		return CodeExecutionResponse{
+			Output:    "",
+			Error:     compileOutput,
+			Status:    "compile_error",
+			VetOutput: vetOutput,
 		}
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Create Go file
-	goFile := filepath.Join(tempDir, "main.go")
-	fullCode := g.createRestrictedCode(code)
+	result := g.execBinary(binPath, tempDir, inputData, execTimeout)
+	if withVet && vetErr != nil && result.Status == "success" {
+		result.Status = "vet_error"
+	}
+	result.VetOutput = vetOutput
+	return result
+}
 
-	err = os.WriteFile(goFile, []byte(fullCode), 0644)
-	if err != nil {
-		return CodeValidationResponse{
-			IsValid:  false,
-			Errors:   []string{fmt.Sprintf("Failed to write code file: %v", err)},
-			Warnings: []string{},
+// runGoTest runs a detected test module with `go test -json` instead of
+// compileCode/execBinary: go test does its own compiling, so there's no
+// separate compile_error phase to report here beyond what `go test` itself
+// surfaces on stderr before any JSON event is emitted (e.g. a syntax error).
+// Status is "runtime_error" when any test fails, "compile_error" when go
+// test never got far enough to run one, and "success" otherwise.
+func (g *GoExecutor) runGoTest(tempDir string, onlyBenchmark bool, execTimeout int) CodeExecutionResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(execTimeout)*time.Second)
+	defer cancel()
+
+	args := []string{"test", "-json"}
+	if onlyBenchmark {
+		args = append(args, "-run", "^$", "-bench", ".")
+	} else {
+		args = append(args, "-run", ".")
+	}
+	args = append(args, "./...")
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = tempDir
+	cmd.Env = g.goEnv(tempDir)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return CodeExecutionResponse{
+			Error:  fmt.Sprintf("Tests timed out after %d seconds", execTimeout),
+			Status: "timeout",
+			IsTest: true,
 		}
 	}
 
-	// Create go.mod file
-	goModContent := "module main\n\ngo 1.21\n"
-	goModFile := filepath.Join(tempDir, "go.mod")
-	err = os.WriteFile(goModFile, []byte(goModContent), 0644)
-	if err != nil {
-		return CodeValidationResponse{
-			IsValid:  false,
-			Errors:   []string{fmt.Sprintf("Failed to create go.mod: %v", err)},
-			Warnings: []string{},
+	results, output, passed, failed := parseGoTestJSON(stdout.Bytes())
+	stderrStr := strings.TrimSpace(stderr.String())
+
+	status := "success"
+	switch {
+	case runErr != nil && len(results) == 0:
+		status = "compile_error"
+	case failed > 0:
+		status = "runtime_error"
+	}
+
+	return CodeExecutionResponse{
+		Output:      strings.TrimSpace(output),
+		Error:       stderrStr,
+		Status:      status,
+		IsTest:      true,
+		TestsPassed: passed,
+		TestsFailed: failed,
+		TestResults: results,
+	}
+}
+
+// goTestEvent is one line of `go test -json` output, as documented by
+// `go doc test2json`.
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Test    string  `json:"Test"`
+	Output  string  `json:"Output"`
+	Elapsed float64 `json:"Elapsed"`
+}
+
+// parseGoTestJSON replays a `go test -json` event stream into per-test
+// TestResults plus the combined output of the whole run. "pass"/"fail"/"skip"
+// events with no Test field are package-level summaries and are skipped;
+// only per-test outcomes are reported.
+func parseGoTestJSON(data []byte) (results []TestResult, combinedOutput string, passed, failed int) {
+	perTestOutput := make(map[string]*strings.Builder)
+	var output strings.Builder
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+
+		switch ev.Action {
+		case "output":
+			output.WriteString(ev.Output)
+			if ev.Test != "" {
+				b, ok := perTestOutput[ev.Test]
+				if !ok {
+					b = &strings.Builder{}
+					perTestOutput[ev.Test] = b
+				}
+				b.WriteString(ev.Output)
+			}
+		case "pass", "fail", "skip":
+			if ev.Test == "" {
+				continue
+			}
+			testOutput := ""
+			if b, ok := perTestOutput[ev.Test]; ok {
+				testOutput = b.String()
+			}
+			results = append(results, TestResult{
+				Name:    ev.Test,
+				Status:  ev.Action,
+				Output:  testOutput,
+				Elapsed: ev.Elapsed,
+			})
+			if ev.Action == "pass" {
+				passed++
+			} else if ev.Action == "fail" {
+				failed++
+			}
 		}
 	}
 
-	// Syntax check
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return results, output.String(), passed, failed
+}
+
+// Event models one line of timed output in the /execute/stream playback
+// protocol: Kind is "stdout", "stderr", or "exit", and Delay is the wall
+// time elapsed since the previous event (for "exit" it's the total wall
+// time of the whole request).
+type Event struct {
+	Delay    time.Duration `json:"delay"`
+	Kind     string        `json:"kind"`
+	Message  string        `json:"message,omitempty"`
+	ExitCode int           `json:"exitCode,omitempty"`
+}
+
+// ExecuteStream compiles and runs code the same way ExecuteCode does, but
+// emits stdout/stderr as a sequence of timed Events via onEvent instead of
+// buffering until the process exits, so long-running or interactive-ish
+// snippets can show output live. It always terminates with a "exit" event.
+func (g *GoExecutor) ExecuteStream(ctx context.Context, code, inputData string, timeout int, withVet bool, files map[string]string, onEvent func(Event)) {
+	execTimeout := g.effectiveTimeout(timeout)
+
+	start := time.Now()
+
+	tempDir, sourceFiles, err := g.prepareBuildDir(code, files)
+	if err != nil {
+		onEvent(Event{Kind: "stderr", Message: err.Error()})
+		onEvent(Event{Delay: time.Since(start), Kind: "exit", Message: "error"})
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	if isTestModule(sourceFiles) {
+		onEvent(Event{Kind: "stderr", Message: "streaming a go test submission is not supported; use /execute instead"})
+		onEvent(Event{Delay: time.Since(start), Kind: "exit", Message: "error"})
+		return
+	}
+
+	var vetOutput string
+	var vetWG sync.WaitGroup
+	if withVet {
+		vetWG.Add(1)
+		go func() {
+			defer vetWG.Done()
+			vetOutput, _ = g.runVet(tempDir)
+		}()
+	}
+
+	binPath := filepath.Join(tempDir, "prog")
+	compileOutput, compileErr := g.compileCode(tempDir, binPath)
+	vetWG.Wait()
+	if vetOutput != "" {
+		onEvent(Event{Delay: time.Since(start), Kind: "stderr", Message: vetOutput})
+	}
+	if compileErr != nil {
+		onEvent(Event{Kind: "stderr", Message: compileOutput})
+		onEvent(Event{Delay: time.Since(start), Kind: "exit", Message: "compile_error"})
+		return
+	}
+
+	g.streamBinary(ctx, binPath, tempDir, inputData, start, execTimeout, onEvent)
+}
+
+// scanPipe reads r line by line, invoking emit for each complete line until
+// the pipe is closed by the writer side.
+func scanPipe(r io.Reader, emit func(string)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		emit(scanner.Text())
+	}
+	// Drain whatever is left (e.g. after an over-long line) so the writer
+	// never blocks on a pipe nobody reads.
+	io.Copy(io.Discard, r)
+}
+
+// streamBinary execs the compiled binary with stdout/stderr wired through
+// io.Pipe readers, emitting a timed Event per line and a final "exit" event
+// carrying the exit code and total wall time since start.
+func (g *GoExecutor) streamBinary(ctx context.Context, binPath, tempDir, inputData string, start time.Time, execTimeout int, onEvent func(Event)) {
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(execTimeout)*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "go", "build", "-o", "/dev/null", "main.go")
-	cmd.Dir = tempDir
-	cmd.Env = []string{
-		"PATH=" + os.Getenv("PATH"),
-		"GOCACHE=" + filepath.Join(tempDir, ".cache"),
-		"HOME=" + tempDir,
-		"GO111MODULE=on",
-		"GOPATH=",
+	cmd, err := g.Sandbox.Command(runCtx, binPath, tempDir, g.MaxMemoryMB)
+	if err != nil {
+		onEvent(Event{Delay: time.Since(start), Kind: "exit", Message: fmt.Sprintf("failed to prepare sandbox: %v", err)})
+		return
+	}
+	cmd.Env = g.goEnv(tempDir)
+	if inputData != "" {
+		cmd.Stdin = strings.NewReader(inputData)
 	}
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
 
-	err = cmd.Run()
+	// mu also guards the onEvent call itself, not just last: onEvent writes
+	// to the underlying http.ResponseWriter, which is not safe for concurrent
+	// use, and stdout/stderr are scanned by two goroutines that can race.
+	var mu sync.Mutex
+	last := time.Now()
+	emit := func(kind, message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		delay := time.Since(last)
+		last = time.Now()
+		onEvent(Event{Delay: delay, Kind: kind, Message: message})
+	}
+
+	var pipeWG sync.WaitGroup
+	pipeWG.Add(2)
+	go func() {
+		defer pipeWG.Done()
+		scanPipe(stdoutR, func(line string) { emit("stdout", line) })
+	}()
+	go func() {
+		defer pipeWG.Done()
+		scanPipe(stderrR, func(line string) { emit("stderr", line) })
+	}()
+
+	if err := cmd.Start(); err != nil {
+		stdoutW.Close()
+		stderrW.Close()
+		pipeWG.Wait()
+		onEvent(Event{Delay: time.Since(start), Kind: "exit", Message: fmt.Sprintf("failed to start: %v", err)})
+		return
+	}
+
+	runErr := cmd.Wait()
+	stdoutW.Close()
+	stderrW.Close()
+	pipeWG.Wait()
+
+	status := "success"
+	exitCode := 0
+	if runCtx.Err() == context.DeadlineExceeded {
+		status = "timeout"
+	} else if runErr != nil {
+		status = "runtime_error"
+		if exitError, ok := runErr.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
+		}
+	}
+
+	onEvent(Event{Delay: time.Since(start), Kind: "exit", Message: status, ExitCode: exitCode})
+}
+
+// ValidateSyntax performs a static analysis pass over code (and any extra
+// Files/txtar payload) using go/parser and go/token instead of invoking the
+// toolchain. See validateSourceFiles for the actual analysis.
+func (g *GoExecutor) ValidateSyntax(code string, files map[string]string) CodeValidationResponse {
+	sourceFiles, err := g.resolveSourceFiles(code, files)
 	if err != nil {
 		return CodeValidationResponse{
 			IsValid:  false,
-			Errors:   []string{strings.TrimSpace(stderr.String())},
+			Errors:   []string{err.Error()},
 			Warnings: []string{},
 		}
 	}
+	return g.validateSourceFiles(sourceFiles)
+}
+
+// validateSourceFiles parses every .go file in the resolved module into an
+// *ast.File, walks each with ast.Inspect to extract ImportSpec paths, and
+// rejects anything not on the availableLibraries allowlist. It also flags
+// disallowed constructs (unsafe, syscall, os/exec, net, plugin,
+// go:linkname/go:cgo_* directives) with precise line/column info, and
+// requires that the module as a whole declares "package main" and a
+// "func main()" somewhere in it (the latter is waived for a module
+// isTestModule flags, since `go test` supplies its own entry point).
+// Because it never shells out to `go build`, it's fast and has no
+// toolchain dependency.
+func (g *GoExecutor) validateSourceFiles(files map[string]string) CodeValidationResponse {
+	errors := []string{}
+	warnings := []string{}
+	foundPackageMain := false
+	foundMainFunc := false
+	testModule := isTestModule(files)
+
+	for name, content := range files {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, name, content, parser.ParseComments)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", name, err.Error()))
+			continue
+		}
+
+		if file.Name != nil && file.Name.Name == "main" {
+			foundPackageMain = true
+		}
+		if hasMainFunc(file) {
+			foundMainFunc = true
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.ImportSpec)
+			if !ok {
+				return true
+			}
+			path := strings.Trim(spec.Path.Value, `"`)
+			pos := fset.Position(spec.Pos())
+			if msg, blocked := disallowedImports[path]; blocked {
+				errors = append(errors, fmt.Sprintf("%s:%d:%d: %s", name, pos.Line, pos.Column, msg))
+				return true
+			}
+			if !importAllowed(path) {
+				errors = append(errors, fmt.Sprintf("%s:%d:%d: import %q is not in the allowed library list", name, pos.Line, pos.Column, path))
+			}
+			return true
+		})
+
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				for _, prefix := range disallowedDirectivePrefixes {
+					if strings.HasPrefix(c.Text, prefix) {
+						pos := fset.Position(c.Pos())
+						errors = append(errors, fmt.Sprintf("%s:%d:%d: directive %q is not allowed", name, pos.Line, pos.Column, strings.TrimSpace(c.Text)))
+					}
+				}
+			}
+		}
+	}
+
+	if !foundPackageMain {
+		errors = append(errors, "module must declare \"package main\"")
+	}
+	if !foundMainFunc && !testModule {
+		errors = append(errors, "module must declare a \"func main()\"")
+	}
 
 	return CodeValidationResponse{
-		IsValid:  true,
-		Errors:   []string{},
-		Warnings: []string{},
+		IsValid:  len(errors) == 0,
+		Errors:   errors,
+		Warnings: warnings,
+	}
+}
+
+// nonCacheableErrorPatterns are stderr substrings that mark a response as
+// non-deterministic, mirroring the Playground sandbox's memcache rules for
+// skipping things like out-of-memory kills. Configurable via
+// CACHE_SKIP_PATTERNS (comma-separated), appended to the defaults.
+var nonCacheableErrorPatterns = buildNonCacheablePatterns()
+
+func buildNonCacheablePatterns() []string {
+	patterns := []string{"out of memory", "cannot allocate memory", "signal: killed"}
+	if extra := os.Getenv("CACHE_SKIP_PATTERNS"); extra != "" {
+		for _, p := range strings.Split(extra, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				patterns = append(patterns, p)
+			}
+		}
+	}
+	return patterns
+}
+
+// cacheKey hashes the request body the same way for /execute and /validate,
+// namespaced by endpoint so the two never collide on the same cache even
+// when their other fields happen to match (e.g. a /validate call and an
+// /execute call against identical code with default inputData/timeout):
+// sha256(namespace || 0x00 || code || 0x00 || inputData || 0x00 || timeout
+// || 0x00 || withVet), plus each Files entry (sorted by name) when a
+// multi-file payload is present. withVet must be folded in because it
+// changes the response shape (VetOutput, and a success->vet_error status
+// flip), not just the request.
+func cacheKey(namespace, code, inputData string, timeout int, withVet bool, files map[string]string) string {
+	h := sha256.New()
+	h.Write([]byte(namespace))
+	h.Write([]byte{0})
+	h.Write([]byte(code))
+	h.Write([]byte{0})
+	h.Write([]byte(inputData))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(timeout)))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatBool(withVet)))
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.Write([]byte{0})
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(files[name]))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isCacheableExecutionResponse rejects timeouts and known-nondeterministic
+// failures so a flaky OOM or kill doesn't get memoized as gospel.
+func isCacheableExecutionResponse(resp CodeExecutionResponse) bool {
+	if resp.Status == "timeout" {
+		return false
+	}
+	if resp.Status == "compile_error" && strings.HasPrefix(resp.Error, "Compilation timed out") {
+		return false
+	}
+	for _, pattern := range nonCacheableErrorPatterns {
+		if strings.Contains(resp.Error, pattern) {
+			return false
+		}
+	}
+	return true
+}
+
+// CacheStats is returned by ResponseCache.Stats and surfaced on /info.
+type CacheStats struct {
+	Backend string `json:"backend"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Size    int    `json:"size"`
+}
+
+// ResponseCache stores already-encoded JSON response bodies keyed by
+// cacheKey. Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Stats() CacheStats
+}
+
+// lruResponseCache is the default in-memory ResponseCache backend, an
+// LRU keyed by cacheKey with a fixed entry capacity.
+type lruResponseCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // front = most recently used
+	hits     uint64
+	misses   uint64
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+func newLRUResponseCache(capacity int) *lruResponseCache {
+	return &lruResponseCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *lruResponseCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.hits++
+	return elem.Value.(*lruEntry).value, true
+}
+
+func (c *lruResponseCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruEntry).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	elem := c.order.PushFront(&lruEntry{key: key, value: value})
+	c.entries[key] = elem
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Backend: "memory",
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Size:    c.order.Len(),
+	}
+}
+
+// redisResponseCache is an optional backend, selected via CACHE_BACKEND=redis,
+// that speaks the Redis RESP protocol directly over a plain TCP connection
+// (GET/SETEX) so the service keeps its zero-dependency build.
+type redisResponseCache struct {
+	addr       string
+	ttlSeconds int
+
+	mu     sync.Mutex
+	hits   uint64
+	misses uint64
+}
+
+func newRedisResponseCache(addr string, ttlSeconds int) *redisResponseCache {
+	return &redisResponseCache{addr: addr, ttlSeconds: ttlSeconds}
+}
+
+func (c *redisResponseCache) dial() (net.Conn, error) {
+	return net.DialTimeout("tcp", c.addr, 2*time.Second)
+}
+
+func respBulkString(args ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return b.String()
+}
+
+func (c *redisResponseCache) Get(key string) ([]byte, bool) {
+	conn, err := c.dial()
+	if err != nil {
+		log.Printf("redis cache GET %s: %v", c.addr, err)
+		return nil, false
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(respBulkString("GET", key))); err != nil {
+		return nil, false
+	}
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, false
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	if len(line) == 0 || line[0] != '$' {
+		return nil, false
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		c.mu.Lock()
+		c.misses++
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	buf := make([]byte, n+2) // payload + trailing CRLF
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+	return buf[:n], true
+}
+
+func (c *redisResponseCache) Set(key string, value []byte) {
+	conn, err := c.dial()
+	if err != nil {
+		log.Printf("redis cache SET %s: %v", c.addr, err)
+		return
+	}
+	defer conn.Close()
+
+	cmd := respBulkString("SETEX", key, strconv.Itoa(c.ttlSeconds), string(value))
+	conn.Write([]byte(cmd))
+
+	reader := bufio.NewReader(conn)
+	reader.ReadString('\n')
+}
+
+func (c *redisResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Backend: "redis",
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Size:    -1, // not tracked locally; size lives in Redis
+	}
+}
+
+// newResponseCache selects a backend based on the CACHE_BACKEND env var:
+// "redis" dials REDIS_ADDR (default "localhost:6379"); anything else falls
+// back to the in-memory LRU sized by CACHE_MAX_ENTRIES (default 256).
+func newResponseCache() ResponseCache {
+	switch os.Getenv("CACHE_BACKEND") {
+	case "redis":
+		addr := os.Getenv("REDIS_ADDR")
+		if addr == "" {
+			addr = "localhost:6379"
+		}
+		ttl := 3600
+		if v, err := strconv.Atoi(os.Getenv("CACHE_TTL_SECONDS")); err == nil && v > 0 {
+			ttl = v
+		}
+		return newRedisResponseCache(addr, ttl)
+	default:
+		capacity := 256
+		if v, err := strconv.Atoi(os.Getenv("CACHE_MAX_ENTRIES")); err == nil && v > 0 {
+			capacity = v
+		}
+		return newLRUResponseCache(capacity)
 	}
 }
 
@@ -338,7 +1558,7 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func executeHandler(executor *GoExecutor) http.HandlerFunc {
+func executeHandler(executor *GoExecutor, cache ResponseCache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Handle CORS preflight
 		if r.Method == http.MethodOptions {
@@ -348,7 +1568,7 @@ func executeHandler(executor *GoExecutor) http.HandlerFunc {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
-		
+
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -360,20 +1580,38 @@ func executeHandler(executor *GoExecutor) http.HandlerFunc {
 			return
 		}
 
-		if req.Code == "" {
+		if req.Code == "" && len(req.Files) == 0 {
 			http.Error(w, "Code is required", http.StatusBadRequest)
 			return
 		}
 
-		result := executor.ExecuteCode(req.Code, req.InputData, req.Timeout)
-
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		json.NewEncoder(w).Encode(result)
+
+		key := cacheKey("exec", req.Code, req.InputData, req.Timeout, req.WithVet, req.Files)
+		if cached, ok := cache.Get(key); ok {
+			w.Write(cached)
+			return
+		}
+
+		result := executor.ExecuteCode(req.Code, req.InputData, req.Timeout, req.WithVet, req.Files)
+
+		body, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if isCacheableExecutionResponse(result) {
+			cache.Set(key, body)
+		}
+		w.Write(body)
 	}
 }
 
-func validateHandler(executor *GoExecutor) http.HandlerFunc {
+// executeStreamHandler serves /execute/stream: the same request shape as
+// /execute, but the response is a Server-Sent Events stream of Events
+// instead of a single JSON blob, so output shows up live as it's produced.
+func executeStreamHandler(executor *GoExecutor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Handle CORS preflight
 		if r.Method == http.MethodOptions {
@@ -383,32 +1621,97 @@ func validateHandler(executor *GoExecutor) http.HandlerFunc {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
-		
+
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req CodeValidationRequest
+		var req CodeExecutionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
 			return
 		}
 
-		if req.Code == "" {
+		if req.Code == "" && len(req.Files) == 0 {
 			http.Error(w, "Code is required", http.StatusBadRequest)
 			return
 		}
 
-		result := executor.ValidateSyntax(req.Code)
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		executor.ExecuteStream(r.Context(), req.Code, req.InputData, req.Timeout, req.WithVet, req.Files, func(ev Event) {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, data)
+			flusher.Flush()
+		})
+	}
+}
+
+func validateHandler(executor *GoExecutor, cache ResponseCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Handle CORS preflight
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req CodeValidationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if req.Code == "" && len(req.Files) == 0 {
+			http.Error(w, "Code is required", http.StatusBadRequest)
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		json.NewEncoder(w).Encode(result)
+
+		key := cacheKey("validate", req.Code, "", 0, false, req.Files)
+		if cached, ok := cache.Get(key); ok {
+			w.Write(cached)
+			return
+		}
+
+		result := executor.ValidateSyntax(req.Code, req.Files)
+
+		body, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+			return
+		}
+		// Validation errors are deterministic static-analysis output, always cacheable.
+		cache.Set(key, body)
+		w.Write(body)
 	}
 }
 
-func infoHandler(executor *GoExecutor) http.HandlerFunc {
+func infoHandler(executor *GoExecutor, cache ResponseCache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		info := map[string]interface{}{
 			"service":             "go-executor",
@@ -417,11 +1720,9 @@ func infoHandler(executor *GoExecutor) http.HandlerFunc {
 			"maxExecutionTime":    executor.MaxExecutionTime,
 			"maxMemoryMB":         executor.MaxMemoryMB,
 			"maxCodeSizeKB":       executor.MaxCodeSizeKB,
-			"availableLibraries": []string{
-				"fmt", "os", "strings", "strconv", "math", "sort",
-				"time", "bufio", "bytes", "io", "regexp", "unicode",
-				"crypto/*", "encoding/*", "path/filepath", "runtime",
-			},
+			"availableLibraries": availableLibraries,
+			"cache":               cache.Stats(),
+			"sandbox":             executor.Sandbox.Name(),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -437,11 +1738,13 @@ func main() {
 	}
 
 	executor := NewGoExecutor()
+	cache := newResponseCache()
 
 	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/execute", executeHandler(executor))
-	http.HandleFunc("/validate", validateHandler(executor))
-	http.HandleFunc("/info", infoHandler(executor))
+	http.HandleFunc("/execute", executeHandler(executor, cache))
+	http.HandleFunc("/execute/stream", executeStreamHandler(executor))
+	http.HandleFunc("/validate", validateHandler(executor, cache))
+	http.HandleFunc("/info", infoHandler(executor, cache))
 
 	fmt.Printf("Go executor service running on port %s\n", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))